@@ -0,0 +1,47 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestGoEnvOverlayTakesPrecedence verifies that SandboxConfig.Env can
+// override both the sandbox's own defaults and the ambient process
+// environment.
+func TestGoEnvOverlayTakesPrecedence(t *testing.T) {
+	os.Setenv("GOPLS_FAKE_TEST_VAR", "ambient")
+	defer os.Unsetenv("GOPLS_FAKE_TEST_VAR")
+
+	sb, err := NewSandbox("env-overlay", "", "", SandboxConfig{
+		Env: []string{
+			"GOSUMDB=sum.golang.org",
+			"GOPLS_FAKE_TEST_VAR=overlay",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Close()
+
+	got := make(map[string]string)
+	for _, kv := range sb.GoEnv() {
+		if i := strings.Index(kv, "="); i >= 0 {
+			got[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	if got["GOSUMDB"] != "sum.golang.org" {
+		t.Errorf("GoEnv()[GOSUMDB] = %q, want overlay value %q", got["GOSUMDB"], "sum.golang.org")
+	}
+	if got["GOPLS_FAKE_TEST_VAR"] != "overlay" {
+		t.Errorf("GoEnv()[GOPLS_FAKE_TEST_VAR] = %q, want overlay to beat the ambient process env", got["GOPLS_FAKE_TEST_VAR"])
+	}
+	if got["GOPATH"] != sb.GOPATH() {
+		t.Errorf("GoEnv()[GOPATH] = %q, want %q", got["GOPATH"], sb.GOPATH())
+	}
+}
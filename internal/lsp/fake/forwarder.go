@@ -0,0 +1,35 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fake
+
+// Forwarder stands in for the lsprpc forwarder that multiplexes LSP
+// sessions from multiple editors onto a single gopls daemon. Each attached
+// session forwards its own Sandbox's InitializationOptions on initialize,
+// so a shared daemon still resolves modules from the correct sandbox-local
+// GOPROXY and GOMODCACHE rather than whichever session dialed in first.
+type Forwarder struct {
+	sessions map[string]*Sandbox
+}
+
+// NewForwarder creates a Forwarder with no sessions attached.
+func NewForwarder() *Forwarder {
+	return &Forwarder{sessions: make(map[string]*Sandbox)}
+}
+
+// Attach associates sessionID with sb, so that a later Initialize call for
+// that session forwards sb's environment.
+func (f *Forwarder) Attach(sessionID string, sb *Sandbox) {
+	f.sessions[sessionID] = sb
+}
+
+// Initialize returns the initializationOptions the daemon should use for
+// sessionID, or nil if no sandbox is attached to that session.
+func (f *Forwarder) Initialize(sessionID string) map[string]interface{} {
+	sb, ok := f.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	return sb.InitializationOptions()
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import "testing"
+
+// TestForwarderPerSessionEnv verifies that two sandboxes with distinct
+// GOPROXY/GOMODCACHE values, attached to sessions behind one Forwarder,
+// each keep resolving modules from their own sandbox rather than leaking
+// into one another.
+func TestForwarderPerSessionEnv(t *testing.T) {
+	sb1, err := NewSandbox("forward-a", "", "-- a.com@v1.0.0/go.mod --\nmodule a.com\n", SandboxConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb1.Close()
+
+	sb2, err := NewSandbox("forward-b", "", "-- b.com@v1.0.0/go.mod --\nmodule b.com\n", SandboxConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb2.Close()
+
+	fwd := NewForwarder()
+	fwd.Attach("session-a", sb1)
+	fwd.Attach("session-b", sb2)
+
+	envA, ok := fwd.Initialize("session-a")["env"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("session-a initializationOptions missing env map")
+	}
+	envB, ok := fwd.Initialize("session-b")["env"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("session-b initializationOptions missing env map")
+	}
+
+	if envA["GOPROXY"] != sb1.Proxy.GOPROXY() {
+		t.Errorf("session-a GOPROXY = %v, want its own sandbox's %q", envA["GOPROXY"], sb1.Proxy.GOPROXY())
+	}
+	if envB["GOPROXY"] != sb2.Proxy.GOPROXY() {
+		t.Errorf("session-b GOPROXY = %v, want its own sandbox's %q", envB["GOPROXY"], sb2.Proxy.GOPROXY())
+	}
+	if envA["GOMODCACHE"] != sb1.modcache {
+		t.Errorf("session-a GOMODCACHE = %v, want its own sandbox's %q", envA["GOMODCACHE"], sb1.modcache)
+	}
+	if envB["GOMODCACHE"] != sb2.modcache {
+		t.Errorf("session-b GOMODCACHE = %v, want its own sandbox's %q", envB["GOMODCACHE"], sb2.modcache)
+	}
+	if envA["GOPROXY"] == envB["GOPROXY"] {
+		t.Errorf("session-a and session-b resolved to the same GOPROXY %v behind a shared forwarder", envA["GOPROXY"])
+	}
+	if envA["GOMODCACHE"] == envB["GOMODCACHE"] {
+		t.Errorf("session-a and session-b resolved to the same GOMODCACHE %v behind a shared forwarder", envA["GOMODCACHE"])
+	}
+}
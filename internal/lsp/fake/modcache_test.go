@@ -0,0 +1,69 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestSandboxOwnsModCacheByDefault verifies that each sandbox gets its own
+// private GOMODCACHE, and that Close removes it.
+func TestSandboxOwnsModCacheByDefault(t *testing.T) {
+	sb, err := NewSandbox("modcache-default", "", "", SandboxConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !sb.sandboxOwnedCache {
+		t.Errorf("sandboxOwnedCache = false, want true for a sandbox with no SharedModCache")
+	}
+	if _, err := os.Stat(sb.modcache); err != nil {
+		t.Errorf("sandbox modcache %s does not exist: %v", sb.modcache, err)
+	}
+
+	var gomodcache string
+	for _, kv := range sb.GoEnv() {
+		if strings.HasPrefix(kv, "GOMODCACHE=") {
+			gomodcache = strings.TrimPrefix(kv, "GOMODCACHE=")
+		}
+	}
+	if gomodcache != sb.modcache {
+		t.Errorf("GoEnv GOMODCACHE = %q, want %q", gomodcache, sb.modcache)
+	}
+
+	modcache := sb.modcache
+	if err := sb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(modcache); !os.IsNotExist(err) {
+		t.Errorf("os.Stat(%s) after Close() = %v, want IsNotExist", modcache, err)
+	}
+}
+
+// TestSandboxSharedModCacheIsNotOwned verifies that a sandbox configured
+// with SharedModCache reports it as unowned, so Close leaves it alone.
+func TestSandboxSharedModCacheIsNotOwned(t *testing.T) {
+	shared, err := ioutil.TempDir("", "fake-shared-modcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(shared)
+
+	sb, err := NewSandbox("modcache-shared", "", "", SandboxConfig{SharedModCache: shared})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Close()
+
+	if sb.sandboxOwnedCache {
+		t.Errorf("sandboxOwnedCache = true, want false for a sandbox with SharedModCache set")
+	}
+	if sb.modcache != shared {
+		t.Errorf("sb.modcache = %q, want %q", sb.modcache, shared)
+	}
+}
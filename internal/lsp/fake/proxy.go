@@ -0,0 +1,133 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Proxy is a file-based implementation of the Go module proxy protocol
+// (see https://golang.org/cmd/go/#hdr-Module_proxy_protocol), rooted at a
+// directory that can be used directly as GOPROXY=file://<dir>.
+type Proxy struct {
+	dir string
+}
+
+// NewProxy creates a file-based proxy rooted at dir, populated from the
+// txtar-encoded content in txt. Files in txt are named
+// "modulePath@version/suffix" (see splitModuleVersionPath); for each
+// module version present, the on-disk proxy protocol layout is generated
+// from the suffix files.
+func NewProxy(dir, txt string) (*Proxy, error) {
+	p := &Proxy{dir: dir}
+	type moduleVersion struct {
+		modulePath, version string
+	}
+	files := make(map[moduleVersion]map[string][]byte)
+	for name, data := range unpackTxt(txt) {
+		modulePath, version, suffix := splitModuleVersionPath(name)
+		if version == "" {
+			continue
+		}
+		mv := moduleVersion{modulePath, version}
+		if files[mv] == nil {
+			files[mv] = make(map[string][]byte)
+		}
+		files[mv][suffix] = data
+	}
+	for mv, suffixFiles := range files {
+		if err := p.WriteModuleVersion(mv.modulePath, mv.version, suffixFiles); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// GOPROXY returns the GOPROXY value that should be used to point the go
+// command at this proxy.
+func (p *Proxy) GOPROXY() string {
+	return "file://" + filepath.ToSlash(p.dir)
+}
+
+// WriteModuleVersion adds or replaces modulePath@version in the proxy,
+// regenerating the @v/list, @v/vX.Y.Z.info, @v/vX.Y.Z.mod and @v/vX.Y.Z.zip
+// files that the module proxy protocol requires. files holds the content of
+// modulePath@version, keyed by path relative to the module root (for
+// example "go.mod" or "pkg/pkg.go"). It is safe to call for a version that
+// already exists: its previous contents are replaced in place.
+func (p *Proxy) WriteModuleVersion(modulePath, version string, files map[string][]byte) error {
+	vdir := filepath.Join(p.dir, modulePath, "@v")
+	if err := os.MkdirAll(vdir, 0755); err != nil {
+		return fmt.Errorf("creating %s: %v", vdir, err)
+	}
+	modData, ok := files["go.mod"]
+	if !ok {
+		modData = []byte(fmt.Sprintf("module %s\n", modulePath))
+	}
+	if err := ioutil.WriteFile(filepath.Join(vdir, version+".mod"), modData, 0644); err != nil {
+		return err
+	}
+	info := fmt.Sprintf(`{"Version":%q,"Time":"2020-01-01T00:00:00Z"}`, version)
+	if err := ioutil.WriteFile(filepath.Join(vdir, version+".info"), []byte(info), 0644); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	prefix := modulePath + "@" + version + "/"
+	for name, data := range files {
+		f, err := zw.Create(prefix + name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(vdir, version+".zip"), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return p.updateVersionList(modulePath, version, true)
+}
+
+// RemoveModuleVersion removes modulePath@version from the proxy, deleting
+// its info/mod/zip files and dropping it from @v/list.
+func (p *Proxy) RemoveModuleVersion(modulePath, version string) error {
+	vdir := filepath.Join(p.dir, modulePath, "@v")
+	for _, ext := range []string{".info", ".mod", ".zip"} {
+		if err := os.Remove(filepath.Join(vdir, version+ext)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return p.updateVersionList(modulePath, version, false)
+}
+
+// updateVersionList rewrites @v/list for modulePath, adding or removing
+// version depending on add.
+func (p *Proxy) updateVersionList(modulePath, version string, add bool) error {
+	listPath := filepath.Join(p.dir, modulePath, "@v", "list")
+	var versions []string
+	if data, err := ioutil.ReadFile(listPath); err == nil {
+		for _, v := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if v != "" && v != version {
+				versions = append(versions, v)
+			}
+		}
+	}
+	if add {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return ioutil.WriteFile(listPath, []byte(strings.Join(versions, "\n")+"\n"), 0644)
+}
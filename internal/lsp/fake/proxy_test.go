@@ -0,0 +1,74 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProxyWriteAndRemoveModuleVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fake-proxy-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, err := NewProxy(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"go.mod": []byte("module mod.com\n"),
+		"p.go":   []byte("package p\n"),
+	}
+	if err := p.WriteModuleVersion("mod.com", "v1.0.0", files); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := ioutil.ReadFile(filepath.Join(dir, "mod.com", "@v", "list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(list)), "v1.0.0"; got != want {
+		t.Errorf("@v/list = %q, want %q", got, want)
+	}
+	for _, ext := range []string{".info", ".mod", ".zip"} {
+		if _, err := ioutil.ReadFile(filepath.Join(dir, "mod.com", "@v", "v1.0.0"+ext)); err != nil {
+			t.Errorf("missing @v/v1.0.0%s: %v", ext, err)
+		}
+	}
+
+	// Writing a second version should add it to the list alongside the
+	// first, not replace it.
+	if err := p.WriteModuleVersion("mod.com", "v1.1.0", files); err != nil {
+		t.Fatal(err)
+	}
+	list, err = ioutil.ReadFile(filepath.Join(dir, "mod.com", "@v", "list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(list)), "v1.0.0\nv1.1.0"; got != want {
+		t.Errorf("@v/list after second write = %q, want %q", got, want)
+	}
+
+	if err := p.RemoveModuleVersion("mod.com", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	list, err = ioutil.ReadFile(filepath.Join(dir, "mod.com", "@v", "list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(list)), "v1.1.0"; got != want {
+		t.Errorf("@v/list after remove = %q, want %q", got, want)
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "mod.com", "@v", "v1.0.0.zip")); err == nil {
+		t.Errorf("v1.0.0.zip still present after RemoveModuleVersion")
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/tools/internal/gocommand"
+	"golang.org/x/tools/internal/lsp/protocol"
+)
+
+// eventTypes returns the set of protocol.FileChangeTypes present in events,
+// keyed by the relative file name they apply to.
+func eventTypes(t *testing.T, dir string, events []FileEvent) map[string]protocol.FileChangeType {
+	t.Helper()
+	types := make(map[string]protocol.FileChangeType)
+	for _, e := range events {
+		rel, err := filepath.Rel(dir, e.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		types[rel] = e.ProtocolEvent.Type
+	}
+	return types
+}
+
+// TestDiffWorkdirSnapshotsCreateModifyDelete exercises the create, modify
+// and delete cases of the generic file-event synthesis that replaced the
+// old hardcoded "go mod init" watcher hack, including a same-size rewrite
+// (the case an mtime+size diff can miss on filesystems with coarse mtime
+// resolution).
+func TestDiffWorkdirSnapshotsCreateModifyDelete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fake-diff-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// kept.go is untouched between snapshots and must not generate an event.
+	// modified.go is rewritten with different content but the SAME length,
+	// so only its mtime changes.
+	// deleted.go exists before and is removed before the second snapshot.
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("kept.go", "package p\n")
+	write("modified.go", "package p // v1\n")
+	write("deleted.go", "package p\n")
+
+	before, err := snapshotDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure the rewrite's mtime is observably different from the original,
+	// even on filesystems with 1s mtime granularity.
+	time.Sleep(1100 * time.Millisecond)
+
+	if err := os.Remove(filepath.Join(dir, "deleted.go")); err != nil {
+		t.Fatal(err)
+	}
+	write("created.go", "package p\n")
+	write("modified.go", "package p // v2\n") // same length as "package p // v1\n"
+
+	after, err := snapshotDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := diffWorkdirSnapshots(before, after)
+	got := eventTypes(t, dir, events)
+
+	want := map[string]protocol.FileChangeType{
+		"created.go":  protocol.Created,
+		"modified.go": protocol.Changed,
+		"deleted.go":  protocol.Deleted,
+	}
+	for name, wantType := range want {
+		gotType, ok := got[name]
+		if !ok {
+			t.Errorf("no FileEvent synthesized for %s; want %v", name, wantType)
+			continue
+		}
+		if gotType != wantType {
+			t.Errorf("event type for %s = %v, want %v", name, gotType, wantType)
+		}
+	}
+	if _, ok := got["kept.go"]; ok {
+		t.Errorf("unexpected FileEvent synthesized for untouched file kept.go")
+	}
+}
+
+// TestSuppressEventsOption verifies that the SuppressEvents GoCommandOption
+// sets the flag RunGoCommand checks before snapshotting and diffing the
+// workdir.
+func TestSuppressEventsOption(t *testing.T) {
+	var cfg goCommandConfig
+	if cfg.suppressEvents {
+		t.Fatalf("zero-value goCommandConfig has suppressEvents = true, want false")
+	}
+	SuppressEvents()(&cfg)
+	if !cfg.suppressEvents {
+		t.Errorf("after SuppressEvents(), cfg.suppressEvents = false, want true")
+	}
+}
+
+// fileWritingRunner is a test-only Runner that, instead of invoking the go
+// command, applies an arbitrary mutation to the sandbox's working
+// directory. It lets RunGoCommand's event-synthesis be exercised without a
+// real go binary, using the same Runner extension point untrusted-module
+// sandboxing relies on.
+type fileWritingRunner struct {
+	mutate func(workdir string) error
+}
+
+func (r fileWritingRunner) Run(ctx context.Context, inv gocommand.Invocation, limits ResourceLimits, network NetworkPolicy) (string, string, int, error) {
+	if err := r.mutate(inv.WorkingDir); err != nil {
+		return "", "", 1, err
+	}
+	return "", "", 0, nil
+}
+
+// TestRunGoCommandSuppressEvents verifies that, with SuppressEvents, a
+// command that mutates the workdir still runs and reports success, without
+// RunGoCommand needing to snapshot the directory at all.
+func TestRunGoCommandSuppressEvents(t *testing.T) {
+	sb, err := NewSandbox("rungocommand-suppress", "", "", SandboxConfig{
+		Runner: fileWritingRunner{
+			mutate: func(workdir string) error {
+				return ioutil.WriteFile(filepath.Join(workdir, "generated.go"), []byte("package p\n"), 0644)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Close()
+
+	result, err := sb.RunGoCommand(context.Background(), "generate", nil, SuppressEvents())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 {
+		t.Errorf("ExitCode = %d, want 0", result.ExitCode)
+	}
+	if _, err := os.Stat(filepath.Join(sb.Workdir.workdir, "generated.go")); err != nil {
+		t.Errorf("generated.go was not written to the workdir: %v", err)
+	}
+}
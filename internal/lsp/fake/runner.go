@@ -0,0 +1,95 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"golang.org/x/tools/internal/gocommand"
+)
+
+// NetworkPolicy controls what network access a Runner grants to the
+// commands it executes.
+type NetworkPolicy int
+
+const (
+	// NetworkOff disables all network access.
+	NetworkOff NetworkPolicy = iota
+	// NetworkProxyOnly allows network access only to the sandbox's module
+	// proxy.
+	NetworkProxyOnly
+	// NetworkUnrestricted allows unrestricted network access.
+	NetworkUnrestricted
+)
+
+// ResourceLimits bounds the resources a Runner grants to the commands it
+// executes. A zero field means "no limit".
+type ResourceLimits struct {
+	CPUSeconds int
+	MemoryMB   int
+	Wallclock  time.Duration
+}
+
+// Runner executes the Go commands a Sandbox runs. Implementations decide
+// what of the host filesystem and network a command can reach and how its
+// CPU, memory and wallclock usage are bounded; ExecRunner is the trivial
+// case that imposes none of that.
+type Runner interface {
+	// Run executes inv under limits and network, returning its output and
+	// exit code. exitCode is -1 if the command could not be run at all, or
+	// its exit status could not be determined.
+	Run(ctx context.Context, inv gocommand.Invocation, limits ResourceLimits, network NetworkPolicy) (stdout, stderr string, exitCode int, err error)
+}
+
+// ExecRunner is the default Runner: it executes Go commands directly on
+// the host, with no additional sandboxing. It is the Runner used when
+// SandboxConfig.Runner is unset, preserving today's behavior.
+type ExecRunner struct{}
+
+// Run implements Runner by invoking the go command directly via
+// gocommand.Runner. limits and network are ignored, since the host
+// environment is already trusted.
+func (ExecRunner) Run(ctx context.Context, inv gocommand.Invocation, limits ResourceLimits, network NetworkPolicy) (string, string, int, error) {
+	gocmdRunner := &gocommand.Runner{}
+	stdout, stderr, _, err := gocmdRunner.RunRaw(ctx, inv)
+	return stdout.String(), stderr.String(), exitCode(err), err
+}
+
+// exitCode extracts the process exit code from err, returning 0 for a nil
+// err and -1 if err did not come from a process exit.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// BubblewrapRunner is a Runner backend that executes Go commands inside a
+// bubblewrap (https://github.com/containers/bubblewrap) sandbox exposing
+// only basedir, GOMODCACHE and GOROOT to the child process. It is not yet
+// implemented.
+type BubblewrapRunner struct{}
+
+// Run returns an error, since BubblewrapRunner is not yet implemented.
+func (BubblewrapRunner) Run(ctx context.Context, inv gocommand.Invocation, limits ResourceLimits, network NetworkPolicy) (string, string, int, error) {
+	return "", "", -1, fmt.Errorf("fake: BubblewrapRunner is not yet implemented")
+}
+
+// ContainerRunner is a Runner backend that executes Go commands inside a
+// throwaway container. It is not yet implemented.
+type ContainerRunner struct{}
+
+// Run returns an error, since ContainerRunner is not yet implemented.
+func (ContainerRunner) Run(ctx context.Context, inv gocommand.Invocation, limits ResourceLimits, network NetworkPolicy) (string, string, int, error) {
+	return "", "", -1, fmt.Errorf("fake: ContainerRunner is not yet implemented")
+}
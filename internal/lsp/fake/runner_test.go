@@ -0,0 +1,39 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fake
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/tools/internal/gocommand"
+)
+
+// TestUnimplementedRunnersReturnError verifies that the unimplemented
+// Runner backends report an error rather than panicking, since they are
+// reachable through the exported SandboxConfig.Runner field.
+func TestUnimplementedRunnersReturnError(t *testing.T) {
+	for _, r := range []Runner{BubblewrapRunner{}, ContainerRunner{}} {
+		_, _, _, err := r.Run(context.Background(), gocommand.Invocation{Verb: "version"}, ResourceLimits{}, NetworkOff)
+		if err == nil {
+			t.Errorf("%T.Run returned a nil error; want a not-yet-implemented error", r)
+		}
+	}
+}
+
+// TestSandboxDefaultsToExecRunner verifies that leaving SandboxConfig.Runner
+// unset falls back to ExecRunner, rather than requiring every caller to
+// specify one explicitly.
+func TestSandboxDefaultsToExecRunner(t *testing.T) {
+	sb, err := NewSandbox("runner-default", "", "", SandboxConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Close()
+
+	if _, ok := sb.runner.(ExecRunner); !ok {
+		t.Errorf("sb.runner = %T, want ExecRunner", sb.runner)
+	}
+}
@@ -23,19 +23,77 @@ type Sandbox struct {
 	name    string
 	gopath  string
 	basedir string
-	env     []string
-	Proxy   *Proxy
-	Workdir *Workdir
+	config  SandboxConfig
+	// modcache is the GOMODCACHE used for this sandbox. sandboxOwnedCache
+	// reports whether it was created for (and is owned by) this sandbox, as
+	// opposed to a cache shared across sandboxes via
+	// SandboxConfig.SharedModCache.
+	modcache          string
+	sandboxOwnedCache bool
+	runner            Runner
+	Proxy             *Proxy
+	Workdir           *Workdir
+}
+
+// EditorConfig holds the LSP session options used to parameterize a fake
+// editor or forwarder talking to a sandboxed gopls instance, such as which
+// code lens categories are advertised and what build flags gopls should
+// use.
+type EditorConfig struct {
+	// CodeLenses disables or enables code lens categories by name (for
+	// example "test", "generate", "upgrade_dependency"). A category absent
+	// from the map uses gopls' default.
+	CodeLenses map[string]bool
+
+	// BuildFlags are added to the "buildFlags" gopls setting.
+	BuildFlags []string
+}
+
+// SandboxConfig controls the construction of a Sandbox.
+type SandboxConfig struct {
+	// Env holds environment variable overrides, in "K=V" form, that take
+	// precedence over both the ambient process environment and the
+	// sandbox's own defaults (GOPATH, GOPROXY, ...). Tests use this to
+	// set sandbox-specific Go environment variables, such as GOSUMDB,
+	// without mutating global process state. Code lenses are configured
+	// through Editor, not through Env.
+	Env []string
+
+	// Editor configures the options reported to gopls when this sandbox is
+	// driven through a fake editor session.
+	Editor EditorConfig
+
+	// SharedModCache, if set, is used as GOMODCACHE instead of a directory
+	// private to this sandbox. It lets tests that don't need module cache
+	// isolation opt into a cache shared across sandboxes, to speed up
+	// suites. The sandbox does not clean a shared cache on Close: it is the
+	// owner's responsibility.
+	SharedModCache string
+
+	// Runner executes the go commands run in this sandbox. If nil, an
+	// ExecRunner is used, preserving today's unrestricted behavior.
+	Runner Runner
+
+	// Limits bounds the resources Runner grants to commands it executes.
+	Limits ResourceLimits
+
+	// Network controls what network access Runner grants to commands it
+	// executes. The zero value is NetworkOff.
+	Network NetworkPolicy
 }
 
 // NewSandbox creates a collection of named temporary resources, with a
 // working directory populated by the txtar-encoded content in srctxt, and a
 // file-based module proxy populated with the txtar-encoded content in
 // proxytxt.
-func NewSandbox(name, srctxt, proxytxt string, env ...string) (_ *Sandbox, err error) {
+func NewSandbox(name, srctxt, proxytxt string, config SandboxConfig) (_ *Sandbox, err error) {
 	sb := &Sandbox{
-		name: name,
-		env:  env,
+		name:   name,
+		config: config,
+		runner: config.Runner,
+	}
+	if sb.runner == nil {
+		sb.runner = ExecRunner{}
 	}
 	defer func() {
 		// Clean up if we fail at any point in this constructor.
@@ -56,6 +114,15 @@ func NewSandbox(name, srctxt, proxytxt string, env ...string) (_ *Sandbox, err e
 			return nil, err
 		}
 	}
+	if config.SharedModCache != "" {
+		sb.modcache = config.SharedModCache
+	} else {
+		sb.modcache = filepath.Join(sb.basedir, "modcache")
+		if err := os.Mkdir(sb.modcache, 0755); err != nil {
+			return nil, err
+		}
+		sb.sandboxOwnedCache = true
+	}
 	sb.Proxy, err = NewProxy(proxydir, proxytxt)
 	sb.Workdir, err = NewWorkdir(workdir, srctxt)
 	return sb, nil
@@ -94,50 +161,209 @@ func (sb *Sandbox) GOPATH() string {
 	return sb.gopath
 }
 
+// Env returns the environment variable overlay configured for this sandbox,
+// so that tests can inspect it without reaching into the original
+// SandboxConfig.
+func (sb *Sandbox) Env() []string {
+	return sb.config.Env
+}
+
 // GoEnv returns the default environment variables that can be used for
-// invoking Go commands in the sandbox.
+// invoking Go commands in the sandbox. It merges, in increasing order of
+// precedence, the ambient process environment, the sandbox's own defaults
+// (GOPATH, GOPROXY, ...), and the overlay supplied via SandboxConfig.Env.
 func (sb *Sandbox) GoEnv() []string {
-	return append([]string{
-		"GOPATH=" + sb.GOPATH(),
-		"GOPROXY=" + sb.Proxy.GOPROXY(),
-		"GO111MODULE=",
-		"GOSUMDB=off",
-	}, sb.env...)
+	envs := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i >= 0 {
+			envs[kv[:i]] = kv[i+1:]
+		}
+	}
+	envs["GOPATH"] = sb.GOPATH()
+	envs["GOPROXY"] = sb.Proxy.GOPROXY()
+	envs["GOMODCACHE"] = sb.modcache
+	envs["GO111MODULE"] = ""
+	envs["GOSUMDB"] = "off"
+	for _, kv := range sb.config.Env {
+		if i := strings.Index(kv, "="); i >= 0 {
+			envs[kv[:i]] = kv[i+1:]
+		}
+	}
+	env := make([]string, 0, len(envs))
+	for k, v := range envs {
+		env = append(env, k+"="+v)
+	}
+	return env
+}
+
+// InitializationOptions packages this sandbox's Go environment into the
+// shape gopls expects under "env" in initializationOptions, so that a
+// forwarded gopls daemon observes the same environment as a sidecar
+// talking to it directly. Only the Go environment variables gopls itself
+// consults (GOPATH, GOPROXY, GOMODCACHE, GOFLAGS, GO111MODULE, GOSUMDB)
+// plus the sandbox's own overlay are included. GOFLAGS is the only value
+// taken from the ambient process environment, and only when it is set
+// there; nothing else from the ambient environment is forwarded.
+func (sb *Sandbox) InitializationOptions() map[string]interface{} {
+	env := map[string]interface{}{
+		"GOPATH":      sb.GOPATH(),
+		"GOPROXY":     sb.Proxy.GOPROXY(),
+		"GOMODCACHE":  sb.modcache,
+		"GO111MODULE": "",
+		"GOSUMDB":     "off",
+	}
+	if goflags, ok := os.LookupEnv("GOFLAGS"); ok {
+		env["GOFLAGS"] = goflags
+	}
+	for _, kv := range sb.config.Env {
+		if i := strings.Index(kv, "="); i >= 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	options := map[string]interface{}{
+		"env": env,
+	}
+	if len(sb.config.Editor.BuildFlags) > 0 {
+		options["buildFlags"] = sb.config.Editor.BuildFlags
+	}
+	if len(sb.config.Editor.CodeLenses) > 0 {
+		options["codelenses"] = sb.config.Editor.CodeLenses
+	}
+	return options
+}
+
+// GoCommandResult holds the output of a go command run in the sandbox.
+type GoCommandResult struct {
+	Stdout, Stderr string
+	// ExitCode is the command's process exit code, or -1 if it could not
+	// be determined (for example, the command never started).
+	ExitCode int
 }
 
-// RunGoCommand executes a go command in the sandbox.
-func (sb *Sandbox) RunGoCommand(ctx context.Context, verb string, args ...string) error {
+// goCommandConfig holds options for RunGoCommand, set via GoCommandOption.
+type goCommandConfig struct {
+	suppressEvents bool
+}
+
+// GoCommandOption configures the behavior of RunGoCommand.
+type GoCommandOption func(*goCommandConfig)
+
+// SuppressEvents prevents RunGoCommand from synthesizing file watcher
+// events for files changed by the command, for tests that want to drive
+// the Workdir's watcher manually.
+func SuppressEvents() GoCommandOption {
+	return func(cfg *goCommandConfig) {
+		cfg.suppressEvents = true
+	}
+}
+
+// RunGoCommand executes a go command in the sandbox. Unless suppressed via
+// SuppressEvents, it synthesizes a Created, Changed or Deleted FileEvent
+// for every file under the working directory that the command added,
+// modified or removed (go.mod and go.sum from `go mod init`/`go mod tidy`,
+// vendor/ from `go mod vendor`, generated files from `go generate`, etc).
+func (sb *Sandbox) RunGoCommand(ctx context.Context, verb string, args []string, opts ...GoCommandOption) (*GoCommandResult, error) {
+	var cfg goCommandConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var before map[string]os.FileInfo
+	if !cfg.suppressEvents {
+		var err error
+		if before, err = sb.snapshotWorkdir(); err != nil {
+			return nil, fmt.Errorf("snapshotting workdir: %v", err)
+		}
+	}
 	inv := gocommand.Invocation{
 		Verb:       verb,
 		Args:       args,
 		WorkingDir: sb.Workdir.workdir,
 		Env:        sb.GoEnv(),
 	}
-	gocmdRunner := &gocommand.Runner{}
-	_, stderr, _, err := gocmdRunner.RunRaw(ctx, inv)
+	stdout, stderr, exit, err := sb.runner.Run(ctx, inv, sb.config.Limits, sb.config.Network)
+	result := &GoCommandResult{
+		Stdout:   stdout,
+		Stderr:   stderr,
+		ExitCode: exit,
+	}
 	if err != nil {
-		return err
-	}
-	// Hardcoded "file watcher": If the command executed was "go mod init",
-	// send a file creation event for a go.mod in the working directory.
-	if strings.HasPrefix(stderr.String(), "go: creating new go.mod") {
-		modpath := filepath.Join(sb.Workdir.workdir, "go.mod")
-		sb.Workdir.sendEvents(ctx, []FileEvent{{
-			Path: modpath,
-			ProtocolEvent: protocol.FileEvent{
-				URI:  toURI(modpath),
-				Type: protocol.Created,
-			},
-		}})
+		return result, err
+	}
+	if !cfg.suppressEvents {
+		after, err := sb.snapshotWorkdir()
+		if err != nil {
+			return result, fmt.Errorf("snapshotting workdir: %v", err)
+		}
+		sb.Workdir.sendEvents(ctx, diffWorkdirSnapshots(before, after))
+	}
+	return result, nil
+}
+
+// snapshotWorkdir records the size and mtime of every regular file under
+// the sandbox's working directory, so that RunGoCommand can detect what a
+// go command changed.
+func (sb *Sandbox) snapshotWorkdir() (map[string]os.FileInfo, error) {
+	return snapshotDir(sb.Workdir.workdir)
+}
+
+// snapshotDir walks dir, recording the size and mtime of every regular
+// file it contains, keyed by path.
+func snapshotDir(dir string) (map[string]os.FileInfo, error) {
+	snapshot := make(map[string]os.FileInfo)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		snapshot[path] = info
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// diffWorkdirSnapshots compares two snapshots taken by snapshotWorkdir,
+// synthesizing a Created, Changed or Deleted FileEvent for every path whose
+// presence, size or mtime differs between them. File contents are not
+// compared, so a rewrite that keeps both size and mtime produces no event.
+func diffWorkdirSnapshots(before, after map[string]os.FileInfo) []FileEvent {
+	var events []FileEvent
+	for path, info := range after {
+		if beforeInfo, ok := before[path]; !ok {
+			events = append(events, newFileEvent(path, protocol.Created))
+		} else if beforeInfo.ModTime() != info.ModTime() || beforeInfo.Size() != info.Size() {
+			events = append(events, newFileEvent(path, protocol.Changed))
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			events = append(events, newFileEvent(path, protocol.Deleted))
+		}
+	}
+	return events
+}
+
+func newFileEvent(path string, typ protocol.FileChangeType) FileEvent {
+	return FileEvent{
+		Path: path,
+		ProtocolEvent: protocol.FileEvent{
+			URI:  toURI(path),
+			Type: typ,
+		},
 	}
-	return nil
 }
 
-// Close removes all state associated with the sandbox.
+// Close removes all state associated with the sandbox. A shared module
+// cache (see SandboxConfig.SharedModCache) is left intact, since it is not
+// owned by this sandbox; the owner is responsible for cleaning it up.
 func (sb *Sandbox) Close() error {
 	var goCleanErr error
-	if sb.gopath != "" {
-		if err := sb.RunGoCommand(context.Background(), "clean", "-modcache"); err != nil {
+	if sb.gopath != "" && sb.sandboxOwnedCache {
+		if _, err := sb.RunGoCommand(context.Background(), "clean", []string{"-modcache"}, SuppressEvents()); err != nil {
 			goCleanErr = fmt.Errorf("cleaning modcache: %v", err)
 		}
 	}